@@ -0,0 +1,64 @@
+package main
+
+import (
+	gc "github.com/rthornton128/goncurses"
+)
+
+// Channels provides the communication primitives views use to request a
+// redraw and to coordinate suspending/resuming the ncurses UI around an
+// external process (an editor or pager taking over the terminal)
+type Channels struct {
+	displayCh   chan bool
+	suspendCh   chan bool
+	suspendedCh chan bool
+	resumeCh    chan bool
+}
+
+// NewChannels creates a new Channels instance
+func NewChannels() *Channels {
+	return &Channels{
+		displayCh:   make(chan bool, 1),
+		suspendCh:   make(chan bool),
+		suspendedCh: make(chan bool),
+		resumeCh:    make(chan bool),
+	}
+}
+
+// UpdateDisplay requests that the UI be redrawn
+func (channels *Channels) UpdateDisplay() {
+	select {
+	case channels.displayCh <- true:
+	default:
+	}
+}
+
+// SuspendUI tears down the ncurses UI so an external process (an editor or
+// pager) can take over the terminal. The main loop honours this by
+// calling gc.End() before running the external command and blocks until
+// ResumeUI is called. SuspendUI returns once gc.End() has been called.
+func (channels *Channels) SuspendUI() {
+	channels.suspendCh <- true
+	<-channels.suspendedCh
+}
+
+// ResumeUI signals the main loop to re-initialise ncurses and force a full
+// redraw once the external process has exited
+func (channels *Channels) ResumeUI() {
+	channels.resumeCh <- true
+	channels.UpdateDisplay()
+}
+
+// RunUISuspendLoop honours SuspendUI/ResumeUI requests by tearing down and
+// re-initialising ncurses around them. The main event loop runs this
+// alongside its normal key-reading loop so views can run external
+// processes (editors, pagers) without the UI fighting them for the
+// terminal.
+func RunUISuspendLoop(channels *Channels) {
+	for {
+		<-channels.suspendCh
+		gc.End()
+		channels.suspendedCh <- true
+		<-channels.resumeCh
+		channels.UpdateDisplay()
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+// Config is the subset of grv's configuration system that views need in
+// order to read user-configurable settings. It's intentionally narrow,
+// mirroring the RenderWindow/Channels pattern of only exposing what the
+// caller in this package actually uses.
+type Config interface {
+	GetInt(key string) (int, error)
+	GetBool(key string) (bool, error)
+}
+
+// Config variable names read by DiffView. maxEntries/maxBytes <= 0 fall
+// back to cfDiffViewDefaultCacheSize/no byte ceiling, the same defaults
+// NewDiffView uses when a key isn't set.
+const (
+	CF_DIFFVIEW_CACHESIZE     = "diffview-cachesize"
+	CF_DIFFVIEW_CACHEMAXBYTES = "diffview-cachemaxbytes"
+)
+
+// CF_MOUSE gates mouse wheel scroll and click handling, disabled by default
+// since some terminals steal the mouse for their own text selection.
+// CF_DIFFVIEW_MOUSE_SCROLL_LINES configures how many lines the viewport
+// moves per wheel notch; <= 0 keeps diffViewDefaultMouseScrollLines.
+const (
+	CF_MOUSE                       = "mouse"
+	CF_DIFFVIEW_MOUSE_SCROLL_LINES = "diffview-mouse-scroll-lines"
+)
@@ -3,47 +3,416 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
 	log "github.com/Sirupsen/logrus"
 	gc "github.com/rthornton128/goncurses"
-	"sync"
 )
 
+// Default number of parsed diffs retained by DiffView's cache. Can be
+// overridden via the CF_DIFFVIEW_CACHESIZE config variable.
+const cfDiffViewDefaultCacheSize = 64
+
 type DiffViewHandler func(*DiffView) error
 
+// DiffLineType describes the kind of content held by a DiffLine
+type DiffLineType int
+
+// The set of supported DiffLineTypes
+const (
+	DLFileHeader DiffLineType = iota
+	DLHunkHeader
+	DLContext
+	DLAddition
+	DLDeletion
+	DLNoNewLine
+	DLBinary
+)
+
+// DiffLine is a single line of a diff and the classification used to render it
 type DiffLine struct {
-	line string
+	line     string
+	lineType DiffLineType
+	segments []Segment
+}
+
+// SegmentKind describes whether a Segment of a DiffLine differs from its
+// paired line in the adjacent deletion/addition run
+type SegmentKind int
+
+// The set of supported SegmentKinds
+const (
+	SegUnchanged SegmentKind = iota
+	SegChanged
+)
+
+// Segment is a byte range of a DiffLine produced by the intra-line word diff
+type Segment struct {
+	start int
+	end   int
+	kind  SegmentKind
+}
+
+// DiffHunk is a contiguous block of changes within a file, introduced by an
+// "@@ -a,b +c,d @@" (or "@@@ ... @@@" for combined diffs) header
+type DiffHunk struct {
+	header                string
+	lines                 []*DiffLine
+	lineIndex             uint
+	intraLineDiffComputed bool
+}
+
+// DiffFile contains the header metadata and hunks for a single file entry
+// within a diff
+type DiffFile struct {
+	oldPath     string
+	newPath     string
+	oldMode     string
+	newMode     string
+	similarity  int
+	binary      bool
+	rename      bool
+	copy        bool
+	headerLines []*DiffLine
+	hunks       []*DiffHunk
+	lineIndex   uint
 }
 
+// Diff is the structured representation of the output of a git diff command
 type Diff struct {
-	lines   []*DiffLine
-	viewPos *ViewPos
+	files           []*DiffFile
+	lines           []*DiffLine
+	fileLineIndices []uint
+	hunkLineIndices []uint
+	wordDiffEnabled bool
+	matches         []Match
+	matchIndex      int
+	viewPos         *ViewPos
+}
+
+// SearchDirection describes which way an incremental search moves through a view
+type SearchDirection int
+
+// The supported SearchDirections
+const (
+	SearchDirectionForward SearchDirection = iota
+	SearchDirectionBackward
+)
+
+// Match is a single result of an incremental search, expressed as a byte
+// range within a line
+type Match struct {
+	lineIndex uint
+	start     int
+	end       int
+}
+
+// SearchPrompt holds the transient state of an incremental search prompt. It
+// has no view-specific fields so it can be reused by any view that wants the
+// same '/'/'?' search UX (CommitView is the next candidate).
+type SearchPrompt struct {
+	active    bool
+	direction SearchDirection
+	input     []rune
+}
+
+// Start begins a new prompt in the given direction
+func (prompt *SearchPrompt) Start(direction SearchDirection) {
+	prompt.active = true
+	prompt.direction = direction
+	prompt.input = nil
+}
+
+// Cancel discards the in-progress prompt without submitting a query
+func (prompt *SearchPrompt) Cancel() {
+	prompt.active = false
+	prompt.input = nil
+}
+
+// AppendRune appends a single typed character to the query being entered
+func (prompt *SearchPrompt) AppendRune(r rune) {
+	prompt.input = append(prompt.input, r)
+}
+
+// Backspace removes the last character of the query being entered
+func (prompt *SearchPrompt) Backspace() {
+	if len(prompt.input) > 0 {
+		prompt.input = prompt.input[:len(prompt.input)-1]
+	}
+}
+
+// Query returns the text entered into the prompt so far
+func (prompt *SearchPrompt) Query() string {
+	return string(prompt.input)
+}
+
+// Prefix returns the character used to indicate the prompt's direction
+func (prompt *SearchPrompt) Prefix() string {
+	if prompt.direction == SearchDirectionBackward {
+		return "?"
+	}
+
+	return "/"
+}
+
+// Submit closes the prompt and returns the entered query
+func (prompt *SearchPrompt) Submit() (query string) {
+	query = prompt.Query()
+	prompt.active = false
+	prompt.input = nil
+
+	return
+}
+
+// diffCacheEntry is a single entry in a DiffCache's LRU list
+type diffCacheEntry struct {
+	oid     string
+	diff    *Diff
+	bytes   int
+	element *list.Element
+}
+
+// DiffCache is a bounded, least-recently-used cache of parsed Diffs keyed by
+// commit OID. Both an entry count and a total byte size ceiling can be
+// configured; either (or both) keep memory use bounded while browsing a
+// large repo's history. When an entry is evicted its viewPos is preserved in
+// a secondary map so scroll position survives being recomputed later.
+type DiffCache struct {
+	maxEntries     int
+	maxBytes       int
+	totalBytes     int
+	entries        map[string]*diffCacheEntry
+	order          *list.List
+	evictedViewPos map[string]*ViewPos
+}
+
+// NewDiffCache creates a new DiffCache. maxEntries <= 0 falls back to
+// cfDiffViewDefaultCacheSize. maxBytes <= 0 disables the byte size ceiling.
+func NewDiffCache(maxEntries, maxBytes int) *DiffCache {
+	if maxEntries <= 0 {
+		maxEntries = cfDiffViewDefaultCacheSize
+	}
+
+	return &DiffCache{
+		maxEntries:     maxEntries,
+		maxBytes:       maxBytes,
+		entries:        make(map[string]*diffCacheEntry),
+		order:          list.New(),
+		evictedViewPos: make(map[string]*ViewPos),
+	}
+}
+
+// Get returns the cached Diff for oid, if present, and marks it as the most
+// recently used entry
+func (cache *DiffCache) Get(oid string) (*Diff, bool) {
+	entry, ok := cache.entries[oid]
+	if !ok {
+		return nil, false
+	}
+
+	cache.order.MoveToFront(entry.element)
+
+	return entry.diff, true
+}
+
+// Put inserts diff into the cache under oid, evicting least-recently-used
+// entries as required to stay within the configured bounds
+func (cache *DiffCache) Put(oid string, diff *Diff) {
+	if existing, ok := cache.entries[oid]; ok {
+		cache.order.Remove(existing.element)
+		cache.totalBytes -= existing.bytes
+		delete(cache.entries, oid)
+	}
+
+	entry := &diffCacheEntry{
+		oid:   oid,
+		diff:  diff,
+		bytes: diffByteSize(diff),
+	}
+
+	entry.element = cache.order.PushFront(entry)
+	cache.entries[oid] = entry
+	cache.totalBytes += entry.bytes
+
+	cache.evict()
+}
+
+// TakeEvictedViewPos returns and clears the viewPos preserved for oid when
+// it was last evicted from the cache, if any
+func (cache *DiffCache) TakeEvictedViewPos(oid string) (viewPos *ViewPos, ok bool) {
+	viewPos, ok = cache.evictedViewPos[oid]
+	if ok {
+		delete(cache.evictedViewPos, oid)
+	}
+
+	return
+}
+
+// evict removes least-recently-used entries until the cache satisfies both
+// the entry count and total byte size bounds. The most-recently-used entry
+// (the one Put/Get last touched) is never evicted, even if it alone
+// exceeds the bounds, so the active commit's diff is never pulled out from
+// under it.
+func (cache *DiffCache) evict() {
+	for cache.order.Len() > 1 && (len(cache.entries) > cache.maxEntries || (cache.maxBytes > 0 && cache.totalBytes > cache.maxBytes)) {
+		back := cache.order.Back()
+		if back == nil {
+			break
+		}
+
+		entry := back.Value.(*diffCacheEntry)
+		cache.order.Remove(back)
+		delete(cache.entries, entry.oid)
+		cache.totalBytes -= entry.bytes
+
+		if entry.diff.viewPos != nil {
+			cache.evictedViewPos[entry.oid] = entry.diff.viewPos
+		}
+
+		log.Debugf("Evicted diff for commit %v from diff view cache", entry.oid)
+	}
+}
+
+// diffByteSize approximates the memory footprint of a parsed Diff's line
+// content, for enforcing the cache's optional byte size ceiling
+func diffByteSize(diff *Diff) (size int) {
+	for _, line := range diff.lines {
+		size += len(line.line)
+	}
+
+	return
+}
+
+// escKey is the key code ncurses delivers for a bare Escape press
+const escKey = gc.Key(27)
+
+// diffViewDefaultMouseScrollLines is the number of lines moved per wheel
+// notch when no CF_DIFFVIEW_MOUSE_SCROLL_LINES config value is set
+const diffViewDefaultMouseScrollLines = 3
+
+// MouseButton identifies the action carried by a MouseEvent
+type MouseButton int
+
+// The set of supported MouseButtons
+const (
+	MouseWheelUp MouseButton = iota
+	MouseWheelDown
+	MouseLeftClick
+)
+
+// MouseEvent is the sibling of KeyPressEvent used to carry mouse input
+// (wheel scroll notches and clicks) through the main input loop to views
+type MouseEvent struct {
+	button MouseButton
+	y      uint
 }
 
 type DiffView struct {
-	channels      *Channels
-	repoData      RepoData
-	activeCommit  *Commit
-	commitDiffs   map[*Commit]*Diff
-	viewPos       *ViewPos
-	viewDimension ViewDimension
-	handlers      map[gc.Key]DiffViewHandler
-	active        bool
-	lock          sync.Mutex
-}
-
-func NewDiffView(repoData RepoData, channels *Channels) *DiffView {
-	return &DiffView{
-		repoData:    repoData,
-		channels:    channels,
-		viewPos:     NewViewPos(),
-		commitDiffs: make(map[*Commit]*Diff),
+	channels            *Channels
+	repoData            RepoData
+	activeCommit        *Commit
+	diffCache           *DiffCache
+	viewPos             *ViewPos
+	viewDimension       ViewDimension
+	handlers            map[gc.Key]DiffViewHandler
+	searchPrompt        SearchPrompt
+	mouseEnabled        bool
+	mouseScrollLines    uint
+	pendingManualScroll bool
+	active              bool
+	lock                sync.Mutex
+}
+
+func NewDiffView(repoData RepoData, channels *Channels, config Config) *DiffView {
+	diffView := &DiffView{
+		repoData:         repoData,
+		channels:         channels,
+		viewPos:          NewViewPos(),
+		diffCache:        NewDiffCache(cfDiffViewDefaultCacheSize, 0),
+		mouseScrollLines: diffViewDefaultMouseScrollLines,
 		handlers: map[gc.Key]DiffViewHandler{
 			gc.KEY_UP:    MoveUpLine,
 			gc.KEY_DOWN:  MoveDownLine,
 			gc.KEY_RIGHT: ScrollDiffViewRight,
 			gc.KEY_LEFT:  ScrollDiffViewLeft,
+			gc.Key(']'):  NextDiffFile,
+			gc.Key('['):  PrevDiffFile,
+			gc.Key('}'):  NextDiffHunk,
+			gc.Key('{'):  PrevDiffHunk,
+			gc.Key('w'):  ToggleWordDiff,
+			gc.Key('/'):  StartSearchForward,
+			gc.Key('?'):  StartSearchBackward,
+			gc.Key('n'):  NextMatch,
+			gc.Key('N'):  PrevMatch,
+			escKey:       ClearSearchMatches,
+			gc.Key('e'):  OpenDiffInEditor,
+			gc.Key('|'):  PipeDiffToPager,
+			gc.Key('P'):  PipeDiffToPager,
+			gc.Key('o'):  OpenFileAtLineInEditor,
 		},
 	}
+
+	cacheSize, _ := config.GetInt(CF_DIFFVIEW_CACHESIZE)
+	cacheMaxBytes, _ := config.GetInt(CF_DIFFVIEW_CACHEMAXBYTES)
+	diffView.SetCacheLimits(cacheSize, cacheMaxBytes)
+
+	mouseEnabled, _ := config.GetBool(CF_MOUSE)
+	diffView.SetMouseEnabled(mouseEnabled)
+
+	mouseScrollLines, _ := config.GetInt(CF_DIFFVIEW_MOUSE_SCROLL_LINES)
+	if mouseScrollLines > 0 {
+		diffView.SetMouseScrollLines(uint(mouseScrollLines))
+	}
+
+	return diffView
+}
+
+// SetCacheLimits configures the maximum number of diffs and/or maximum
+// total bytes the diff cache retains, e.g. from grv's config when it's
+// loaded. maxEntries <= 0 keeps cfDiffViewDefaultCacheSize; maxBytes <= 0
+// disables the byte size ceiling.
+func (diffView *DiffView) SetCacheLimits(maxEntries, maxBytes int) {
+	diffView.lock.Lock()
+	defer diffView.lock.Unlock()
+
+	if maxEntries <= 0 {
+		maxEntries = cfDiffViewDefaultCacheSize
+	}
+
+	diffView.diffCache.maxEntries = maxEntries
+	diffView.diffCache.maxBytes = maxBytes
+	diffView.diffCache.evict()
+}
+
+// SetMouseEnabled toggles handling of mouse wheel scroll and click events.
+// Disabled by default, since some terminals steal the mouse for their own
+// text selection.
+func (diffView *DiffView) SetMouseEnabled(enabled bool) {
+	diffView.lock.Lock()
+	defer diffView.lock.Unlock()
+
+	diffView.mouseEnabled = enabled
+}
+
+// SetMouseScrollLines configures how many lines the viewport moves per
+// wheel notch. lines == 0 keeps diffViewDefaultMouseScrollLines.
+func (diffView *DiffView) SetMouseScrollLines(lines uint) {
+	diffView.lock.Lock()
+	defer diffView.lock.Unlock()
+
+	if lines == 0 {
+		lines = diffViewDefaultMouseScrollLines
+	}
+
+	diffView.mouseScrollLines = lines
 }
 
 func (diffView *DiffView) Initialise() (err error) {
@@ -62,23 +431,65 @@ func (diffView *DiffView) Render(win RenderWindow) (err error) {
 
 	rows := win.Rows() - 2
 	viewPos := diffView.viewPos
-	viewPos.DetermineViewStartRow(rows)
 
-	diff := diffView.commitDiffs[diffView.activeCommit]
+	// A manual wheel scroll already positioned viewStartRowIndex for this
+	// frame; calling DetermineViewStartRow here would immediately recentre
+	// the viewport back on the selected row and make the scroll a no-op.
+	// Skip it for this one frame, then resume following the selection as
+	// normal on subsequent renders (e.g. after the next key press).
+	if diffView.pendingManualScroll {
+		diffView.pendingManualScroll = false
+	} else {
+		viewPos.DetermineViewStartRow(rows)
+	}
+
+	diff := diffView.activeDiff()
 	lineNum := uint(len(diff.lines))
 	lineIndex := viewPos.viewStartRowIndex
 	startColumn := viewPos.viewStartColumn
 
 	for rowIndex := uint(0); rowIndex < rows && lineIndex < lineNum; rowIndex++ {
-		if err = win.SetRow(rowIndex+1, startColumn, " %v", diff.lines[lineIndex].line); err != nil {
+		line := diff.lines[lineIndex]
+
+		if diff.wordDiffEnabled && len(line.segments) > 0 && (line.lineType == DLAddition || line.lineType == DLDeletion) {
+			err = win.SetRowWithHighlights(rowIndex+1, startColumn, diffLineThemeComponentID(line.lineType),
+				diffHighlightThemeComponentID(line.lineType), line.segments, " %v", line.line)
+		} else {
+			err = win.SetRow(rowIndex+1, startColumn, diffLineThemeComponentID(line.lineType), " %v", line.line)
+		}
+
+		if err != nil {
 			return
 		}
 
+		for matchIndex, match := range diff.matches {
+			if match.lineIndex != lineIndex || match.start < int(startColumn) {
+				continue
+			}
+
+			component := CMP_DIFFVIEW_SEARCH_MATCH
+			if matchIndex == diff.matchIndex {
+				component = CMP_DIFFVIEW_SEARCH_MATCH_ACTIVE
+			}
+
+			column := uint(1+match.start) - startColumn
+			length := uint(match.end - match.start)
+
+			if err = win.Highlight(rowIndex+1, column, length, component); err != nil {
+				return
+			}
+		}
+
 		lineIndex++
 	}
 
-	if err = win.SetSelectedRow((viewPos.activeRowIndex-viewPos.viewStartRowIndex)+1, diffView.active); err != nil {
-		return
+	// activeRowIndex can end up behind viewStartRowIndex if a manual scroll
+	// pushes the viewport past the selected row; the row is then off the
+	// top of the screen, so there's nothing to mark as selected this frame.
+	if viewPos.activeRowIndex >= viewPos.viewStartRowIndex {
+		if err = win.SetSelectedRow((viewPos.activeRowIndex-viewPos.viewStartRowIndex)+1, diffView.active); err != nil {
+			return
+		}
 	}
 
 	win.DrawBorder()
@@ -87,13 +498,50 @@ func (diffView *DiffView) Render(win RenderWindow) (err error) {
 		return
 	}
 
-	if err = win.SetFooter(CMP_COMMITVIEW_FOOTER, "Line %v of %v", viewPos.activeRowIndex+1, lineNum); err != nil {
+	if diffView.searchPrompt.active {
+		err = win.SetFooter(CMP_DIFFVIEW_SEARCH_PROMPT, "%v%v", diffView.searchPrompt.Prefix(), diffView.searchPrompt.Query())
+	} else {
+		err = win.SetFooter(CMP_COMMITVIEW_FOOTER, "Line %v of %v", viewPos.activeRowIndex+1, lineNum)
+	}
+
+	if err != nil {
 		return
 	}
 
 	return
 }
 
+// diffLineThemeComponentID returns the theme component used to render a line
+// of the given type
+func diffLineThemeComponentID(lineType DiffLineType) ThemeComponentID {
+	switch lineType {
+	case DLFileHeader:
+		return CMP_DIFFVIEW_FILEHEADER
+	case DLHunkHeader:
+		return CMP_DIFFVIEW_HUNKHEADER
+	case DLAddition:
+		return CMP_DIFFVIEW_ADDITION
+	case DLDeletion:
+		return CMP_DIFFVIEW_DELETION
+	default:
+		return CMP_DIFFVIEW_NORMAL
+	}
+}
+
+// diffHighlightThemeComponentID returns the theme component used to render
+// the SegChanged portions of an addition or deletion line when word diff is
+// enabled
+func diffHighlightThemeComponentID(lineType DiffLineType) ThemeComponentID {
+	switch lineType {
+	case DLAddition:
+		return CMP_DIFFVIEW_ADDITION_HIGHLIGHT
+	case DLDeletion:
+		return CMP_DIFFVIEW_DELETION_HIGHLIGHT
+	default:
+		return CMP_DIFFVIEW_NORMAL
+	}
+}
+
 func (diffView *DiffView) OnActiveChange(active bool) {
 	log.Debugf("DiffView active: %v", active)
 	diffView.lock.Lock()
@@ -106,11 +554,15 @@ func (diffView *DiffView) OnCommitSelect(commit *Commit) (err error) {
 	diffView.lock.Lock()
 	defer diffView.lock.Unlock()
 
-	if diff, ok := diffView.commitDiffs[diffView.activeCommit]; ok {
-		diff.viewPos = diffView.viewPos
+	if diffView.activeCommit != nil {
+		if diff, ok := diffView.diffCache.Get(diffView.activeCommit.commit.Id().String()); ok {
+			diff.viewPos = diffView.viewPos
+		}
 	}
 
-	if diff, ok := diffView.commitDiffs[commit]; ok {
+	oid := commit.commit.Id().String()
+
+	if diff, ok := diffView.diffCache.Get(oid); ok {
 		diffView.activeCommit = commit
 		diffView.viewPos = diff.viewPos
 		diffView.channels.UpdateDisplay()
@@ -122,31 +574,313 @@ func (diffView *DiffView) OnCommitSelect(commit *Commit) (err error) {
 		return
 	}
 
+	diff := parseDiff(buf)
+
+	if viewPos, ok := diffView.diffCache.TakeEvictedViewPos(oid); ok {
+		diff.viewPos = viewPos
+	} else {
+		diff.viewPos = NewViewPos()
+	}
+
+	diffView.diffCache.Put(oid, diff)
+	diffView.activeCommit = commit
+	diffView.viewPos = diff.viewPos
+	diffView.channels.UpdateDisplay()
+
+	return
+}
+
+// activeDiff returns the parsed Diff for the currently active commit, or nil
+// if there isn't one cached (e.g. it's in the process of being parsed)
+func (diffView *DiffView) activeDiff() *Diff {
+	if diffView.activeCommit == nil {
+		return nil
+	}
+
+	diff, _ := diffView.diffCache.Get(diffView.activeCommit.commit.Id().String())
+
+	return diff
+}
+
+// parseDiff parses the raw output of a git diff command into a structured
+// Diff, classifying each line and grouping lines into DiffHunks and
+// DiffHunks into DiffFiles. It tolerates merge/combined diffs ("@@@ ... @@@")
+// and the "\ No newline at end of file" marker.
+func parseDiff(buf *bytes.Buffer) *Diff {
+	diff := &Diff{matchIndex: -1}
+
 	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
-	var lines []*DiffLine
+	var currentFile *DiffFile
+	var currentHunk *DiffHunk
+
+	appendLine := func(line *DiffLine) {
+		diff.lines = append(diff.lines, line)
+	}
 
 	for scanner.Scan() {
-		lines = append(lines, &DiffLine{
-			line: scanner.Text(),
-		})
+		text := scanner.Text()
+		lineIndex := uint(len(diff.lines))
+
+		switch {
+		case strings.HasPrefix(text, "diff --git ") || strings.HasPrefix(text, "diff --cc ") || strings.HasPrefix(text, "diff --combined "):
+			currentFile = &DiffFile{lineIndex: lineIndex}
+			currentHunk = nil
+			diff.files = append(diff.files, currentFile)
+			diff.fileLineIndices = append(diff.fileLineIndices, lineIndex)
+
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+			currentFile.headerLines = append(currentFile.headerLines, line)
+
+		case strings.HasPrefix(text, "old mode "), strings.HasPrefix(text, "new mode "),
+			strings.HasPrefix(text, "deleted file mode "), strings.HasPrefix(text, "new file mode "),
+			strings.HasPrefix(text, "index "):
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+			if currentFile != nil {
+				currentFile.headerLines = append(currentFile.headerLines, line)
+
+				if mode := strings.TrimPrefix(text, "new mode "); mode != text {
+					currentFile.newMode = mode
+				} else if mode := strings.TrimPrefix(text, "new file mode "); mode != text {
+					currentFile.newMode = mode
+				} else if mode := strings.TrimPrefix(text, "old mode "); mode != text {
+					currentFile.oldMode = mode
+				}
+			}
+
+		case strings.HasPrefix(text, "similarity index "):
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+
+			if currentFile != nil {
+				currentFile.headerLines = append(currentFile.headerLines, line)
+				percent := strings.TrimSuffix(strings.TrimPrefix(text, "similarity index "), "%")
+				if similarity, convErr := strconv.Atoi(percent); convErr == nil {
+					currentFile.similarity = similarity
+				}
+			}
+
+		case strings.HasPrefix(text, "rename from "), strings.HasPrefix(text, "rename to "):
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+			if currentFile != nil {
+				currentFile.headerLines = append(currentFile.headerLines, line)
+				currentFile.rename = true
+			}
+
+		case strings.HasPrefix(text, "copy from "), strings.HasPrefix(text, "copy to "):
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+			if currentFile != nil {
+				currentFile.headerLines = append(currentFile.headerLines, line)
+				currentFile.copy = true
+			}
+
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			line := &DiffLine{line: text, lineType: DLFileHeader}
+			appendLine(line)
+
+			if currentFile != nil {
+				currentFile.headerLines = append(currentFile.headerLines, line)
+				path := strings.TrimPrefix(strings.TrimPrefix(text, "--- "), "+++ ")
+
+				switch {
+				case strings.HasPrefix(text, "--- "):
+					currentFile.oldPath = trimDiffPathPrefix(path)
+				case strings.HasPrefix(text, "+++ "):
+					currentFile.newPath = trimDiffPathPrefix(path)
+				}
+			}
+
+		case strings.HasPrefix(text, "Binary files ") || strings.HasPrefix(text, "GIT binary patch"):
+			line := &DiffLine{line: text, lineType: DLBinary}
+			appendLine(line)
+			if currentFile != nil {
+				currentFile.binary = true
+				currentFile.headerLines = append(currentFile.headerLines, line)
+			}
+
+		case strings.HasPrefix(text, "@@"):
+			currentHunk = &DiffHunk{header: text, lineIndex: lineIndex}
+			if currentFile != nil {
+				currentFile.hunks = append(currentFile.hunks, currentHunk)
+			}
+
+			diff.hunkLineIndices = append(diff.hunkLineIndices, lineIndex)
+			line := &DiffLine{line: text, lineType: DLHunkHeader}
+			appendLine(line)
+			currentHunk.lines = append(currentHunk.lines, line)
+
+		case strings.HasPrefix(text, "\\ No newline at end of file"):
+			line := &DiffLine{line: text, lineType: DLNoNewLine}
+			appendLine(line)
+			if currentHunk != nil {
+				currentHunk.lines = append(currentHunk.lines, line)
+			}
+
+		default:
+			markerWidth := 1
+			if currentHunk != nil {
+				markerWidth = hunkMarkerWidth(currentHunk.header)
+			}
+
+			lineType := diffLineTypeForContentLine(text, markerWidth)
+			line := &DiffLine{line: text, lineType: lineType}
+			appendLine(line)
+
+			if currentHunk != nil {
+				currentHunk.lines = append(currentHunk.lines, line)
+			}
+		}
 	}
 
-	diffView.commitDiffs[commit] = &Diff{
-		lines: lines,
+	return diff
+}
+
+// hunkMarkerWidth returns the number of leading marker columns git prefixes
+// content lines with inside a hunk: 1 for a normal two-way diff ("@@ ... @@")
+// and one per parent for a combined/merge diff ("@@@ ... @@@" has 2, and so
+// on), derived by counting the hunk header's leading '@' run.
+func hunkMarkerWidth(header string) int {
+	atCount := 0
+	for atCount < len(header) && header[atCount] == '@' {
+		atCount++
 	}
 
-	diffView.activeCommit = commit
-	diffView.viewPos = NewViewPos()
-	diffView.channels.UpdateDisplay()
+	if atCount < 2 {
+		return 1
+	}
+
+	return atCount - 1
+}
+
+// diffLineTypeForContentLine classifies a line that appears within a hunk,
+// given the fixed marker width (number of leading '+'/'-'/' ' columns)
+// derived from the enclosing hunk's header. A fixed width is required
+// because content that itself starts with '+'/'-' characters would
+// otherwise be misread as extra markers.
+func diffLineTypeForContentLine(text string, markerWidth int) DiffLineType {
+	if text == "" {
+		return DLContext
+	}
+
+	width := markerWidth
+	if width > len(text) {
+		width = len(text)
+	}
+	markers := text[:width]
+
+	switch {
+	case strings.Count(markers, "+") == width:
+		return DLAddition
+	case strings.Count(markers, "-") == width:
+		return DLDeletion
+	default:
+		return DLContext
+	}
+}
+
+// trimDiffPathPrefix strips the leading a/ or b/ prefix git uses for paths
+func trimDiffPathPrefix(path string) string {
+	switch {
+	case strings.HasPrefix(path, "a/"), strings.HasPrefix(path, "b/"):
+		return path[2:]
+	default:
+		return path
+	}
+}
+
+// HandleMouseEvent processes a mouse wheel scroll or click event. It is the
+// mouse sibling of Handle, dispatched by the main input loop when the event
+// read from the terminal is a MouseEvent rather than a key press. Disabled
+// by default, since some terminals steal the mouse for their own text
+// selection; callers enable it with SetMouseEnabled once the user's config
+// has been read.
+func (diffView *DiffView) HandleMouseEvent(event MouseEvent) (err error) {
+	if !diffView.mouseEnabled {
+		return
+	}
+
+	diffView.lock.Lock()
+	defer diffView.lock.Unlock()
+
+	diff := diffView.activeDiff()
+	if diff == nil {
+		return
+	}
+
+	switch event.button {
+	case MouseWheelUp:
+		diffView.scrollViewport(diff, diffView.mouseScrollLines, true)
+	case MouseWheelDown:
+		diffView.scrollViewport(diff, diffView.mouseScrollLines, false)
+	case MouseLeftClick:
+		diffView.selectRowAtMouseY(diff, event.y)
+	}
 
 	return
 }
 
+// scrollViewport moves the viewport up or down by lines without changing
+// the selected row, matching the scroll-vs-click distinction of gocui's
+// patch explorer: wheel notches scroll the view, clicks change selection.
+func (diffView *DiffView) scrollViewport(diff *Diff, lines uint, up bool) {
+	viewPos := diffView.viewPos
+	lineNum := uint(len(diff.lines))
+
+	if up {
+		if viewPos.viewStartRowIndex > lines {
+			viewPos.viewStartRowIndex -= lines
+		} else {
+			viewPos.viewStartRowIndex = 0
+		}
+	} else {
+		maxStart := uint(0)
+		if lineNum > 0 {
+			maxStart = lineNum - 1
+		}
+
+		viewPos.viewStartRowIndex += lines
+		if viewPos.viewStartRowIndex > maxStart {
+			viewPos.viewStartRowIndex = maxStart
+		}
+	}
+
+	diffView.pendingManualScroll = true
+	diffView.channels.UpdateDisplay()
+}
+
+// selectRowAtMouseY sets the active row to the diff line under a left click
+// at terminal row y, computed from the view's border offset and current
+// scroll position
+func (diffView *DiffView) selectRowAtMouseY(diff *Diff, y uint) {
+	const borderOffset = 1
+	if y < borderOffset {
+		return
+	}
+
+	viewPos := diffView.viewPos
+	lineIndex := viewPos.viewStartRowIndex + (y - borderOffset)
+
+	if lineIndex >= uint(len(diff.lines)) {
+		return
+	}
+
+	viewPos.activeRowIndex = lineIndex
+	diffView.channels.UpdateDisplay()
+}
+
 func (diffView *DiffView) Handle(keyPressEvent KeyPressEvent) (err error) {
 	log.Debugf("DiffView handling key %v", keyPressEvent)
 	diffView.lock.Lock()
 	defer diffView.lock.Unlock()
 
+	if diffView.searchPrompt.active {
+		diffView.handleSearchInput(keyPressEvent)
+		return
+	}
+
 	if handler, ok := diffView.handlers[keyPressEvent.key]; ok {
 		err = handler(diffView)
 	}
@@ -154,8 +888,180 @@ func (diffView *DiffView) Handle(keyPressEvent KeyPressEvent) (err error) {
 	return
 }
 
+// handleSearchInput processes a key press while the incremental search
+// prompt is active, building up the query or submitting/cancelling it
+func (diffView *DiffView) handleSearchInput(keyPressEvent KeyPressEvent) {
+	switch keyPressEvent.key {
+	case escKey:
+		diffView.searchPrompt.Cancel()
+		diffView.channels.UpdateDisplay()
+	case gc.KEY_RETURN, gc.KEY_ENTER:
+		diffView.submitSearch()
+	case gc.KEY_BACKSPACE:
+		diffView.searchPrompt.Backspace()
+		diffView.channels.UpdateDisplay()
+	default:
+		if keyPressEvent.key > 0 && keyPressEvent.key < 256 {
+			diffView.searchPrompt.AppendRune(rune(keyPressEvent.key))
+			diffView.channels.UpdateDisplay()
+		}
+	}
+}
+
+// submitSearch closes the search prompt, computes the matches for the
+// entered query and jumps to the nearest one in the search direction
+func (diffView *DiffView) submitSearch() {
+	query := diffView.searchPrompt.Submit()
+	direction := diffView.searchPrompt.direction
+
+	diff := diffView.activeDiff()
+	if diff == nil || query == "" {
+		diffView.channels.UpdateDisplay()
+		return
+	}
+
+	diff.matches = findDiffMatches(diff, query)
+	diff.matchIndex = -1
+
+	if len(diff.matches) > 0 {
+		diffView.jumpToMatch(nearestMatchIndex(diff, diffView.viewPos.activeRowIndex, direction))
+	}
+
+	diffView.channels.UpdateDisplay()
+}
+
+// findDiffMatches finds every occurrence of query within diff's lines. query
+// is compiled as a regular expression; if that fails it's instead matched as
+// a plain substring.
+func findDiffMatches(diff *Diff, query string) (matches []Match) {
+	re, regexErr := regexp.Compile(query)
+
+	for lineIndex, line := range diff.lines {
+		if regexErr == nil {
+			for _, loc := range re.FindAllStringIndex(line.line, -1) {
+				matches = append(matches, Match{lineIndex: uint(lineIndex), start: loc[0], end: loc[1]})
+			}
+
+			continue
+		}
+
+		searchFrom := 0
+		for {
+			idx := strings.Index(line.line[searchFrom:], query)
+			if idx < 0 {
+				break
+			}
+
+			start := searchFrom + idx
+			matches = append(matches, Match{lineIndex: uint(lineIndex), start: start, end: start + len(query)})
+			searchFrom = start + len(query)
+		}
+	}
+
+	return
+}
+
+// nearestMatchIndex returns the index of the match closest to fromRow in the
+// given direction, wrapping around the ends of the match list
+func nearestMatchIndex(diff *Diff, fromRow uint, direction SearchDirection) int {
+	if direction == SearchDirectionForward {
+		for i, match := range diff.matches {
+			if match.lineIndex >= fromRow {
+				return i
+			}
+		}
+
+		return 0
+	}
+
+	for i := len(diff.matches) - 1; i >= 0; i-- {
+		if diff.matches[i].lineIndex <= fromRow {
+			return i
+		}
+	}
+
+	return len(diff.matches) - 1
+}
+
+// jumpToMatch moves the active row to the match at index
+func (diffView *DiffView) jumpToMatch(index int) {
+	diff := diffView.activeDiff()
+	if diff == nil || index < 0 || index >= len(diff.matches) {
+		return
+	}
+
+	diff.matchIndex = index
+	diffView.viewPos.activeRowIndex = diff.matches[index].lineIndex
+}
+
+// StartSearchForward opens the incremental search prompt to search forwards
+func StartSearchForward(diffView *DiffView) (err error) {
+	return diffView.startSearch(SearchDirectionForward)
+}
+
+// StartSearchBackward opens the incremental search prompt to search backwards
+func StartSearchBackward(diffView *DiffView) (err error) {
+	return diffView.startSearch(SearchDirectionBackward)
+}
+
+func (diffView *DiffView) startSearch(direction SearchDirection) (err error) {
+	if diffView.activeDiff() == nil {
+		return
+	}
+
+	diffView.searchPrompt.Start(direction)
+	diffView.channels.UpdateDisplay()
+
+	return
+}
+
+// NextMatch moves to the next search match, wrapping to the first match at the end
+func NextMatch(diffView *DiffView) (err error) {
+	return diffView.advanceMatch(SearchDirectionForward)
+}
+
+// PrevMatch moves to the previous search match, wrapping to the last match at the start
+func PrevMatch(diffView *DiffView) (err error) {
+	return diffView.advanceMatch(SearchDirectionBackward)
+}
+
+func (diffView *DiffView) advanceMatch(direction SearchDirection) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil || len(diff.matches) == 0 {
+		return
+	}
+
+	if direction == SearchDirectionForward {
+		diffView.jumpToMatch((diff.matchIndex + 1) % len(diff.matches))
+	} else {
+		index := diff.matchIndex - 1
+		if index < 0 {
+			index = len(diff.matches) - 1
+		}
+		diffView.jumpToMatch(index)
+	}
+
+	diffView.channels.UpdateDisplay()
+
+	return
+}
+
+// ClearSearchMatches discards the active search's matches
+func ClearSearchMatches(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil || len(diff.matches) == 0 {
+		return
+	}
+
+	diff.matches = nil
+	diff.matchIndex = -1
+	diffView.channels.UpdateDisplay()
+
+	return
+}
+
 func MoveDownLine(diffView *DiffView) (err error) {
-	diff := diffView.commitDiffs[diffView.activeCommit]
+	diff := diffView.activeDiff()
 	lineNum := uint(len(diff.lines))
 	viewPos := diffView.viewPos
 
@@ -196,4 +1102,461 @@ func ScrollDiffViewLeft(diffView *DiffView) (err error) {
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+// NextDiffFile moves the active row to the header of the next file in the diff
+func NextDiffFile(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	viewPos := diffView.viewPos
+
+	for _, lineIndex := range diff.fileLineIndices {
+		if lineIndex > viewPos.activeRowIndex {
+			viewPos.activeRowIndex = lineIndex
+			log.Debugf("Moving to next file in diff view at line %v", lineIndex)
+			diffView.channels.UpdateDisplay()
+			return
+		}
+	}
+
+	return
+}
+
+// PrevDiffFile moves the active row to the header of the previous file in the diff
+func PrevDiffFile(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	viewPos := diffView.viewPos
+
+	for i := len(diff.fileLineIndices) - 1; i >= 0; i-- {
+		lineIndex := diff.fileLineIndices[i]
+
+		if lineIndex < viewPos.activeRowIndex {
+			viewPos.activeRowIndex = lineIndex
+			log.Debugf("Moving to previous file in diff view at line %v", lineIndex)
+			diffView.channels.UpdateDisplay()
+			return
+		}
+	}
+
+	return
+}
+
+// NextDiffHunk moves the active row to the header of the next hunk in the diff
+func NextDiffHunk(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	viewPos := diffView.viewPos
+
+	for _, lineIndex := range diff.hunkLineIndices {
+		if lineIndex > viewPos.activeRowIndex {
+			viewPos.activeRowIndex = lineIndex
+			log.Debugf("Moving to next hunk in diff view at line %v", lineIndex)
+			diffView.channels.UpdateDisplay()
+			return
+		}
+	}
+
+	return
+}
+
+// PrevDiffHunk moves the active row to the header of the previous hunk in the diff
+func PrevDiffHunk(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	viewPos := diffView.viewPos
+
+	for i := len(diff.hunkLineIndices) - 1; i >= 0; i-- {
+		lineIndex := diff.hunkLineIndices[i]
+
+		if lineIndex < viewPos.activeRowIndex {
+			viewPos.activeRowIndex = lineIndex
+			log.Debugf("Moving to previous hunk in diff view at line %v", lineIndex)
+			diffView.channels.UpdateDisplay()
+			return
+		}
+	}
+
+	return
+}
+
+// ToggleWordDiff toggles intra-line word level highlighting of additions and
+// deletions for the active diff. The underlying word diff is computed lazily,
+// the first time it's needed, and then cached on the Diff's hunks so
+// subsequent toggles and re-renders are cheap.
+func ToggleWordDiff(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil {
+		return
+	}
+
+	diff.wordDiffEnabled = !diff.wordDiffEnabled
+	log.Debugf("Setting diff view word diff enabled: %v", diff.wordDiffEnabled)
+
+	if diff.wordDiffEnabled {
+		for _, file := range diff.files {
+			for _, hunk := range file.hunks {
+				computeIntraLineDiff(hunk)
+			}
+		}
+	}
+
+	diffView.channels.UpdateDisplay()
+
+	return
+}
+
+// diffWordTokenPattern splits a diff line into words, runs of whitespace and
+// individual punctuation characters for the purposes of intra-line diffing
+var diffWordTokenPattern = regexp.MustCompile(`\w+|\s+|[^\w\s]`)
+
+// computeIntraLineDiff pairs up adjacent deletion/addition runs within a hunk
+// and computes a word level diff between each pair, storing the result as
+// Segments on the relevant DiffLines
+func computeIntraLineDiff(hunk *DiffHunk) {
+	if hunk.intraLineDiffComputed {
+		return
+	}
+	hunk.intraLineDiffComputed = true
+
+	lines := hunk.lines
+	markerWidth := hunkMarkerWidth(hunk.header)
+
+	for i := 0; i < len(lines); {
+		if lines[i].lineType != DLDeletion {
+			i++
+			continue
+		}
+
+		deletions := lines[i:]
+		deletionCount := 0
+		for deletionCount < len(deletions) && deletions[deletionCount].lineType == DLDeletion {
+			deletionCount++
+		}
+		deletions = deletions[:deletionCount]
+		i += deletionCount
+
+		additions := lines[i:]
+		additionCount := 0
+		for additionCount < len(additions) && additions[additionCount].lineType == DLAddition {
+			additionCount++
+		}
+		additions = additions[:additionCount]
+		i += additionCount
+
+		pairs := deletionCount
+		if additionCount < pairs {
+			pairs = additionCount
+		}
+
+		for p := 0; p < pairs; p++ {
+			computeLineWordDiff(deletions[p], additions[p], markerWidth)
+		}
+	}
+}
+
+// computeLineWordDiff tokenises a paired deletion and addition line, finds
+// their longest common subsequence of tokens and records the unchanged and
+// changed byte ranges of each as Segments. markerWidth is the number of
+// leading marker columns to skip, derived from the enclosing hunk's header.
+func computeLineWordDiff(oldLine, newLine *DiffLine, markerWidth int) {
+	oldOffset := diffLineMarkerLength(oldLine.line, markerWidth)
+	newOffset := diffLineMarkerLength(newLine.line, markerWidth)
+
+	oldTokens := diffWordTokenPattern.FindAllStringIndex(oldLine.line[oldOffset:], -1)
+	newTokens := diffWordTokenPattern.FindAllStringIndex(newLine.line[newOffset:], -1)
+
+	oldWords := make([]string, len(oldTokens))
+	for i, token := range oldTokens {
+		oldWords[i] = oldLine.line[oldOffset+token[0] : oldOffset+token[1]]
+	}
+
+	newWords := make([]string, len(newTokens))
+	for i, token := range newTokens {
+		newWords[i] = newLine.line[newOffset+token[0] : newOffset+token[1]]
+	}
+
+	oldMatched, newMatched := lcsMatchedTokens(oldWords, newWords)
+
+	oldLine.segments = buildDiffSegments(oldTokens, oldOffset, oldMatched)
+	newLine.segments = buildDiffSegments(newTokens, newOffset, newMatched)
+}
+
+// diffLineMarkerLength returns the number of leading marker characters to
+// skip for line, clamped to markerWidth (the fixed marker width derived from
+// the enclosing hunk's header) rather than scanned, since scanning would
+// misread content that itself starts with '+'/'-' characters as markers.
+func diffLineMarkerLength(line string, markerWidth int) int {
+	if markerWidth > len(line) {
+		return len(line)
+	}
+
+	return markerWidth
+}
+
+// lcsMatchedTokens computes the longest common subsequence of two token
+// sequences and returns, for each sequence, whether each token is part of it
+func lcsMatchedTokens(a, b []string) (aMatched, bMatched []bool) {
+	n, m := len(a), len(b)
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	aMatched = make([]bool, n)
+	bMatched = make([]bool, m)
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			aMatched[i] = true
+			bMatched[j] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return
+}
+
+// buildDiffSegments converts a sequence of token byte ranges, together with
+// whether each token matched, into a minimal list of merged Segments
+func buildDiffSegments(tokens [][]int, offset int, matched []bool) []Segment {
+	var segments []Segment
+
+	for i, token := range tokens {
+		kind := SegChanged
+		if matched[i] {
+			kind = SegUnchanged
+		}
+
+		start := offset + token[0]
+		end := offset + token[1]
+
+		if n := len(segments); n > 0 && segments[n-1].kind == kind && segments[n-1].end == start {
+			segments[n-1].end = end
+		} else {
+			segments = append(segments, Segment{start: start, end: end, kind: kind})
+		}
+	}
+
+	return segments
+}
+
+// OpenDiffInEditor writes the active diff to a temp file and opens it in
+// $GRV_EDITOR/$EDITOR, suspending the ncurses UI for the duration
+func OpenDiffInEditor(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil {
+		return
+	}
+
+	path, err := writeDiffToTempFile(diff)
+	if err != nil {
+		log.Errorf("Unable to write diff to temp file: %v", err)
+		return nil
+	}
+	defer os.Remove(path)
+
+	return diffView.runExternalCommand(exec.Command(diffViewEditorCommand(), path))
+}
+
+// PipeDiffToPager pipes the active diff into $PAGER (default "less -R"),
+// suspending the ncurses UI for the duration
+func PipeDiffToPager(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil {
+		return
+	}
+
+	pagerArgs := diffViewPagerCommand()
+	cmd := exec.Command(pagerArgs[0], pagerArgs[1:]...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Errorf("Unable to open pipe to pager: %v", err)
+		return nil
+	}
+
+	go func() {
+		defer stdin.Close()
+
+		for _, line := range diff.lines {
+			fmt.Fprintln(stdin, line.line)
+		}
+	}()
+
+	return diffView.runExternalCommand(cmd)
+}
+
+// OpenFileAtLineInEditor opens the post-image file referenced by the hunk
+// under the cursor in $GRV_EDITOR/$EDITOR, positioned at the corresponding
+// line. This only makes sense when the file still exists in the worktree,
+// e.g. when viewing the diff against HEAD.
+func OpenFileAtLineInEditor(diffView *DiffView) (err error) {
+	diff := diffView.activeDiff()
+	if diff == nil {
+		return
+	}
+
+	file, hunk := diffView.currentFileAndHunk(diff)
+	if file == nil || hunk == nil || file.newPath == "" {
+		return
+	}
+
+	lineNum, ok := lineNumberInHunk(hunk, diffView.viewPos.activeRowIndex)
+	if !ok {
+		return
+	}
+
+	return diffView.runExternalCommand(exec.Command(diffViewEditorCommand(), fmt.Sprintf("+%v", lineNum), file.newPath))
+}
+
+// currentFileAndHunk returns the DiffFile and DiffHunk the active row
+// currently falls within
+func (diffView *DiffView) currentFileAndHunk(diff *Diff) (file *DiffFile, hunk *DiffHunk) {
+	activeRow := diffView.viewPos.activeRowIndex
+
+	for _, candidate := range diff.files {
+		if candidate.lineIndex > activeRow {
+			break
+		}
+
+		file = candidate
+	}
+
+	if file == nil {
+		return
+	}
+
+	for _, candidate := range file.hunks {
+		if candidate.lineIndex > activeRow {
+			break
+		}
+
+		hunk = candidate
+	}
+
+	return
+}
+
+// hunkHeaderNewRangePattern extracts the new-file start line from a hunk
+// header, e.g. "@@ -12,3 +15,4 @@" or the "+c,d" portion of a combined diff
+// header
+var hunkHeaderNewRangePattern = regexp.MustCompile(`\+(\d+)`)
+
+// lineNumberInHunk computes the line number in the post-image file that
+// corresponds to activeRow, which must fall within hunk
+func lineNumberInHunk(hunk *DiffHunk, activeRow uint) (lineNum int, ok bool) {
+	if activeRow < hunk.lineIndex {
+		return
+	}
+
+	match := hunkHeaderNewRangePattern.FindStringSubmatch(hunk.header)
+	if match == nil {
+		return
+	}
+
+	newStart, convErr := strconv.Atoi(match[1])
+	if convErr != nil {
+		return
+	}
+
+	lineNum = newStart
+	ok = true
+
+	for i, line := range hunk.lines {
+		lineIndex := hunk.lineIndex + uint(i)
+		if lineIndex >= activeRow {
+			break
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		switch line.lineType {
+		case DLContext, DLAddition:
+			lineNum++
+		}
+	}
+
+	return
+}
+
+// writeDiffToTempFile writes the rendered lines of diff to a new temp file
+// and returns its path
+func writeDiffToTempFile(diff *Diff) (path string, err error) {
+	tmpFile, err := ioutil.TempFile("", "grv-diff-*.diff")
+	if err != nil {
+		return
+	}
+	defer tmpFile.Close()
+
+	for _, line := range diff.lines {
+		if _, err = fmt.Fprintln(tmpFile, line.line); err != nil {
+			return
+		}
+	}
+
+	path = tmpFile.Name()
+
+	return
+}
+
+// diffViewEditorCommand returns the editor to use for diff view's editor
+// integration, preferring $GRV_EDITOR over $EDITOR and falling back to vi
+func diffViewEditorCommand() string {
+	if editor := os.Getenv("GRV_EDITOR"); editor != "" {
+		return editor
+	}
+
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+
+	return "vi"
+}
+
+// diffViewPagerCommand returns the pager command (and any arguments) to use
+// for diff view's pager integration, preferring $PAGER and falling back to
+// "less -R"
+func diffViewPagerCommand() []string {
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return strings.Fields(pager)
+	}
+
+	return []string{"less", "-R"}
+}
+
+// runExternalCommand suspends the ncurses UI, runs cmd with its stdio
+// connected to the terminal and resumes the UI once it exits
+func (diffView *DiffView) runExternalCommand(cmd *exec.Cmd) (err error) {
+	diffView.channels.SuspendUI()
+	defer diffView.channels.ResumeUI()
+
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if runErr := cmd.Run(); runErr != nil {
+		log.Errorf("Error running external command %v: %v", cmd.Args, runErr)
+	}
+
+	return
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLcsMatchedTokensMarksOnlyCommonSubsequence(t *testing.T) {
+	a := []string{"foo", " ", "bar", " ", "baz"}
+	b := []string{"foo", " ", "qux", " ", "baz"}
+
+	aMatched, bMatched := lcsMatchedTokens(a, b)
+
+	expectedAMatched := []bool{true, true, false, true, true}
+	expectedBMatched := []bool{true, true, false, true, true}
+
+	if !reflect.DeepEqual(aMatched, expectedAMatched) {
+		t.Errorf("aMatched = %v, want %v", aMatched, expectedAMatched)
+	}
+
+	if !reflect.DeepEqual(bMatched, expectedBMatched) {
+		t.Errorf("bMatched = %v, want %v", bMatched, expectedBMatched)
+	}
+}
+
+func TestLcsMatchedTokensWithNoCommonTokens(t *testing.T) {
+	a := []string{"foo"}
+	b := []string{"bar"}
+
+	aMatched, bMatched := lcsMatchedTokens(a, b)
+
+	if aMatched[0] || bMatched[0] {
+		t.Errorf("expected no tokens to match, got aMatched=%v bMatched=%v", aMatched, bMatched)
+	}
+}
+
+func TestLcsMatchedTokensWithEmptyInput(t *testing.T) {
+	aMatched, bMatched := lcsMatchedTokens(nil, []string{"foo"})
+
+	if len(aMatched) != 0 {
+		t.Errorf("expected aMatched to be empty, got %v", aMatched)
+	}
+
+	if bMatched[0] {
+		t.Errorf("expected bMatched[0] to be false, got %v", bMatched)
+	}
+}
+
+func TestComputeLineWordDiffMarksChangedWordOnly(t *testing.T) {
+	oldLine := &DiffLine{line: "-foo bar baz", lineType: DLDeletion}
+	newLine := &DiffLine{line: "+foo qux baz", lineType: DLAddition}
+
+	computeLineWordDiff(oldLine, newLine, 1)
+
+	// buildDiffSegments merges a word token into an immediately adjacent
+	// same-kind whitespace token, so "foo"/"bar" may come back fused with
+	// their neighbouring space (e.g. "foo " rather than a bare "foo");
+	// check containment rather than exact equality.
+	foundChanged := false
+	for _, segment := range oldLine.segments {
+		if segment.kind == SegChanged && strings.Contains(oldLine.line[segment.start:segment.end], "bar") {
+			foundChanged = true
+		}
+	}
+
+	if !foundChanged {
+		t.Errorf("expected oldLine segments to mark %q as changed, got %v", "bar", oldLine.segments)
+	}
+
+	foundUnchanged := false
+	for _, segment := range oldLine.segments {
+		if segment.kind == SegUnchanged && strings.Contains(oldLine.line[segment.start:segment.end], "foo") {
+			foundUnchanged = true
+		}
+	}
+
+	if !foundUnchanged {
+		t.Errorf("expected oldLine segments to mark %q as unchanged, got %v", "foo", oldLine.segments)
+	}
+}
+
+func diffWithLines(totalBytes int) *Diff {
+	return &Diff{lines: []*DiffLine{{line: strings.Repeat("a", totalBytes)}}}
+}
+
+func TestDiffCacheEvictsLeastRecentlyUsedEntry(t *testing.T) {
+	cache := NewDiffCache(2, 0)
+
+	cache.Put("a", diffWithLines(1))
+	cache.Put("b", diffWithLines(1))
+	cache.Put("c", diffWithLines(1))
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected least-recently-used entry %q to have been evicted", "a")
+	}
+
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("expected entry %q to still be cached", "b")
+	}
+
+	if _, ok := cache.Get("c"); !ok {
+		t.Errorf("expected entry %q to still be cached", "c")
+	}
+}
+
+func TestDiffCacheGetRefreshesRecency(t *testing.T) {
+	cache := NewDiffCache(2, 0)
+
+	cache.Put("a", diffWithLines(1))
+	cache.Put("b", diffWithLines(1))
+	cache.Get("a")
+	cache.Put("c", diffWithLines(1))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected entry %q to have been evicted after %q was refreshed", "b", "a")
+	}
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("expected refreshed entry %q to still be cached", "a")
+	}
+}
+
+func TestDiffCacheNeverEvictsJustInsertedEntry(t *testing.T) {
+	cache := NewDiffCache(10, 5)
+
+	cache.Put("large", diffWithLines(100))
+
+	if _, ok := cache.Get("large"); !ok {
+		t.Errorf("expected just-inserted entry to remain cached even though it alone exceeds maxBytes")
+	}
+}
+
+func TestDiffCacheEnforcesByteCeiling(t *testing.T) {
+	cache := NewDiffCache(10, 10)
+
+	cache.Put("a", diffWithLines(6))
+	cache.Put("b", diffWithLines(6))
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected entry %q to have been evicted to stay within maxBytes", "a")
+	}
+
+	if cache.totalBytes != 6 {
+		t.Errorf("cache.totalBytes = %v, want %v", cache.totalBytes, 6)
+	}
+}
+
+func TestDiffCachePreservesEvictedViewPos(t *testing.T) {
+	cache := NewDiffCache(1, 0)
+
+	evictedDiff := diffWithLines(1)
+	evictedDiff.viewPos = NewViewPos()
+	cache.Put("a", evictedDiff)
+	cache.Put("b", diffWithLines(1))
+
+	if _, ok := cache.TakeEvictedViewPos("a"); !ok {
+		t.Errorf("expected viewPos for evicted entry %q to have been preserved", "a")
+	}
+
+	if _, ok := cache.TakeEvictedViewPos("a"); ok {
+		t.Errorf("expected TakeEvictedViewPos to clear the preserved viewPos after it's taken")
+	}
+}
+
+func diffWithLineContent(lines ...string) *Diff {
+	diff := &Diff{}
+
+	for _, line := range lines {
+		diff.lines = append(diff.lines, &DiffLine{line: line})
+	}
+
+	return diff
+}
+
+func TestFindDiffMatchesUsesRegexWhenQueryCompiles(t *testing.T) {
+	diff := diffWithLineContent("foo bar", "foobar", "baz")
+
+	matches := findDiffMatches(diff, "foo.?bar")
+
+	if len(matches) != 2 {
+		t.Fatalf("len(matches) = %v, want %v", len(matches), 2)
+	}
+
+	if matches[0].lineIndex != 0 || matches[1].lineIndex != 1 {
+		t.Errorf("unexpected match line indices: %+v", matches)
+	}
+}
+
+func TestFindDiffMatchesFallsBackToSubstringWhenQueryIsNotValidRegex(t *testing.T) {
+	diff := diffWithLineContent("a(b", "a(b again", "no match here")
+
+	matches := findDiffMatches(diff, "a(b")
+
+	if len(matches) != 2 {
+		t.Fatalf("len(matches) = %v, want %v", len(matches), 2)
+	}
+
+	for _, match := range matches {
+		if match.end-match.start != len("a(b") {
+			t.Errorf("match = %+v, want length %v", match, len("a(b"))
+		}
+	}
+}
+
+func TestFindDiffMatchesFindsAllOccurrencesOnALine(t *testing.T) {
+	diff := diffWithLineContent("foo foo foo")
+
+	matches := findDiffMatches(diff, "foo")
+
+	if len(matches) != 3 {
+		t.Fatalf("len(matches) = %v, want %v", len(matches), 3)
+	}
+}
+
+func TestNearestMatchIndexForward(t *testing.T) {
+	diff := &Diff{matches: []Match{{lineIndex: 1}, {lineIndex: 5}, {lineIndex: 9}}}
+
+	if got := nearestMatchIndex(diff, 6, SearchDirectionForward); got != 2 {
+		t.Errorf("nearestMatchIndex(fromRow=6, forward) = %v, want %v", got, 2)
+	}
+}
+
+func TestNearestMatchIndexForwardWrapsAround(t *testing.T) {
+	diff := &Diff{matches: []Match{{lineIndex: 1}, {lineIndex: 5}}}
+
+	if got := nearestMatchIndex(diff, 10, SearchDirectionForward); got != 0 {
+		t.Errorf("nearestMatchIndex(fromRow=10, forward) = %v, want %v", got, 0)
+	}
+}
+
+func TestNearestMatchIndexBackward(t *testing.T) {
+	diff := &Diff{matches: []Match{{lineIndex: 1}, {lineIndex: 5}, {lineIndex: 9}}}
+
+	if got := nearestMatchIndex(diff, 6, SearchDirectionBackward); got != 1 {
+		t.Errorf("nearestMatchIndex(fromRow=6, backward) = %v, want %v", got, 1)
+	}
+}
+
+func TestNearestMatchIndexBackwardWrapsAround(t *testing.T) {
+	diff := &Diff{matches: []Match{{lineIndex: 5}, {lineIndex: 9}}}
+
+	if got := nearestMatchIndex(diff, 0, SearchDirectionBackward); got != 1 {
+		t.Errorf("nearestMatchIndex(fromRow=0, backward) = %v, want %v", got, 1)
+	}
+}
+
+func TestScrollViewportSetsPendingManualScroll(t *testing.T) {
+	diffView := &DiffView{channels: NewChannels(), viewPos: NewViewPos()}
+	diff := diffWithLineContent("a", "b", "c", "d")
+
+	diffView.scrollViewport(diff, 2, false)
+
+	if diffView.viewPos.viewStartRowIndex != 2 {
+		t.Errorf("viewStartRowIndex = %v, want %v", diffView.viewPos.viewStartRowIndex, 2)
+	}
+
+	if !diffView.pendingManualScroll {
+		t.Errorf("expected pendingManualScroll to be set after scrollViewport")
+	}
+
+	// Render consults and clears pendingManualScroll before deciding whether
+	// to call DetermineViewStartRow; that part isn't exercised here, since
+	// Render needs a real *Commit. This only confirms scrollViewport itself
+	// doesn't move the selection.
+	if diffView.viewPos.activeRowIndex != 0 {
+		t.Errorf("expected scrollViewport to leave activeRowIndex unchanged, got %v", diffView.viewPos.activeRowIndex)
+	}
+}
+
+func TestHunkMarkerWidth(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"@@ -1,3 +1,3 @@", 1},
+		{"@@@ -1,3 -1,3 +1,3 @@@", 2},
+		{"@@", 1},
+	}
+
+	for _, test := range tests {
+		if got := hunkMarkerWidth(test.header); got != test.want {
+			t.Errorf("hunkMarkerWidth(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestDiffLineMarkerLengthClampsToLineLength(t *testing.T) {
+	tests := []struct {
+		line        string
+		markerWidth int
+		want        int
+	}{
+		{"+added line", 1, 1},
+		{"++added in both parents", 2, 2},
+		{"+", 2, 1},
+	}
+
+	for _, test := range tests {
+		if got := diffLineMarkerLength(test.line, test.markerWidth); got != test.want {
+			t.Errorf("diffLineMarkerLength(%q, %v) = %v, want %v", test.line, test.markerWidth, got, test.want)
+		}
+	}
+}
+
+func TestDiffLineTypeForContentLinePreservesLeadingContentCharacters(t *testing.T) {
+	// A deleted source line that itself starts with "-verbose flag" becomes
+	// the diff text "--verbose flag"; with a two-way diff's marker width of
+	// 1, only the first '-' is the marker and the rest is real content.
+	if got := diffLineTypeForContentLine("--verbose flag", 1); got != DLDeletion {
+		t.Errorf(`diffLineTypeForContentLine("--verbose flag", 1) = %v, want %v`, got, DLDeletion)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// MouseEventHandler is implemented by views that support mouse input.
+// DiffView is the first implementation.
+type MouseEventHandler interface {
+	HandleMouseEvent(event MouseEvent) error
+}
+
+// DispatchMouseEvent routes a MouseEvent read by the main input loop to
+// the active view, if that view supports mouse input. Views that don't
+// implement MouseEventHandler silently ignore mouse input, the same way
+// Handle is only dispatched to views that accept key presses.
+func DispatchMouseEvent(activeView interface{}, event MouseEvent) error {
+	handler, ok := activeView.(MouseEventHandler)
+	if !ok {
+		return nil
+	}
+
+	return handler.HandleMouseEvent(event)
+}
@@ -0,0 +1,27 @@
+package main
+
+// Additional ThemeComponentIDs used by DiffView's structured diff rendering.
+// ThemeComponentID itself and the view-agnostic component IDs (e.g.
+// CMP_COMMITVIEW_TITLE/CMP_COMMITVIEW_FOOTER) are declared alongside the
+// other views' component IDs; these are the ones DiffView's structured diff
+// output introduces.
+const (
+	CMP_DIFFVIEW_FILEHEADER ThemeComponentID = iota
+	CMP_DIFFVIEW_HUNKHEADER
+	CMP_DIFFVIEW_ADDITION
+	CMP_DIFFVIEW_DELETION
+	CMP_DIFFVIEW_NORMAL
+
+	// CMP_DIFFVIEW_ADDITION_HIGHLIGHT and CMP_DIFFVIEW_DELETION_HIGHLIGHT
+	// render the SegChanged portions of addition/deletion lines when word
+	// diff is enabled
+	CMP_DIFFVIEW_ADDITION_HIGHLIGHT
+	CMP_DIFFVIEW_DELETION_HIGHLIGHT
+
+	// CMP_DIFFVIEW_SEARCH_MATCH and CMP_DIFFVIEW_SEARCH_MATCH_ACTIVE render
+	// incremental search matches, and CMP_DIFFVIEW_SEARCH_PROMPT renders the
+	// footer while a search is being entered
+	CMP_DIFFVIEW_SEARCH_MATCH
+	CMP_DIFFVIEW_SEARCH_MATCH_ACTIVE
+	CMP_DIFFVIEW_SEARCH_PROMPT
+)
@@ -0,0 +1,31 @@
+package main
+
+// ViewDimension describes the number of rows and columns a View has
+// available to render into
+type ViewDimension struct {
+	rows uint
+	cols uint
+}
+
+// RenderWindow is the drawing surface a View renders itself onto. It
+// abstracts over the underlying ncurses window.
+type RenderWindow interface {
+	Rows() uint
+	Cols() uint
+	ViewDimensions() ViewDimension
+	SetRow(rowIndex, startColumn uint, themeComponentID ThemeComponentID, format string, args ...interface{}) error
+
+	// SetRowWithHighlights behaves like SetRow but additionally renders
+	// segments of the row using highlightThemeComponentID rather than
+	// themeComponentID, for segments whose SegmentKind is SegChanged
+	SetRowWithHighlights(rowIndex, startColumn uint, themeComponentID, highlightThemeComponentID ThemeComponentID, segments []Segment, format string, args ...interface{}) error
+
+	// Highlight applies themeComponentID to the given column range of an
+	// already rendered row, without otherwise altering its content
+	Highlight(rowIndex, startColumn, length uint, themeComponentID ThemeComponentID) error
+
+	SetSelectedRow(rowIndex uint, selected bool) error
+	SetTitle(themeComponentID ThemeComponentID, format string, args ...interface{}) error
+	SetFooter(themeComponentID ThemeComponentID, format string, args ...interface{}) error
+	DrawBorder()
+}